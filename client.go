@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// DNField identifies the certificate Subject DN as the field a
+// 'tls_client_auth' client is identified by. It is currently the only value
+// GetCertificateSubjectFieldName supports.
+const DNField = "dn"
+
+// Client represents a client able to request OAuth 2.0 tokens.
+type Client interface {
+	// GetID returns the client's ID.
+	GetID() string
+
+	// GetHashedSecret returns the hashed secret as it is stored in the store.
+	GetHashedSecret() []byte
+
+	// IsPublic returns true if this client is marked as public, which
+	// exempts it from client authentication.
+	IsPublic() bool
+
+	// GetCertificateSubjectFieldName returns which field of the TLS client
+	// certificate's Subject Distinguished Name this client is identified by
+	// for 'tls_client_auth'. Currently only DNField is supported.
+	GetCertificateSubjectFieldName() string
+
+	// GetCertificateSubjectValue returns the expected value of the field
+	// named by GetCertificateSubjectFieldName, as an RFC 4514 distinguished
+	// name string.
+	GetCertificateSubjectValue() string
+}
+
+// OpenIDConnectClient extends Client with the metadata needed to support
+// OpenID Connect specific client authentication methods (private_key_jwt,
+// client_secret_jwt, self_signed_tls_client_auth).
+type OpenIDConnectClient interface {
+	Client
+
+	// GetJSONWebKeys returns the client's registered JSON Web Key Set, or
+	// nil if the client instead registered a JSON Web Keys URI.
+	GetJSONWebKeys() *jose.JSONWebKeySet
+
+	// GetJSONWebKeysURI returns the location of the client's JSON Web Key
+	// Set, or an empty string if the client instead registered its keys
+	// directly via GetJSONWebKeys.
+	GetJSONWebKeysURI() string
+
+	// GetTokenEndpointAuthMethod returns the client authentication method
+	// registered for the token endpoint.
+	GetTokenEndpointAuthMethod() string
+
+	// GetTokenEndpointAuthSigningAlgorithm returns the signing algorithm
+	// required of a private_key_jwt / client_secret_jwt client assertion.
+	GetTokenEndpointAuthSigningAlgorithm() string
+}
+
+// ClientManager stores and retrieves OAuth 2.0 clients, as well as the
+// replay-protection markers used for client_assertion 'jti' values.
+type ClientManager interface {
+	// GetClient loads the client by its ID.
+	GetClient(ctx context.Context, id string) (Client, error)
+
+	// ClientAssertionJWTValid returns an error if jti was already used.
+	ClientAssertionJWTValid(ctx context.Context, jti string) error
+
+	// SetClientAssertionJWT marks jti as used until exp.
+	SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error
+}
+
+// Hasher hashes and compares client secrets.
+type Hasher interface {
+	// Compare compares data with a hash and returns an error if they don't match.
+	Compare(ctx context.Context, hash, data []byte) error
+
+	// Hash hashes data.
+	Hash(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// JWKSFetcherStrategy resolves a JSON Web Key Set from its location,
+// synchronously, with an optional forced cache bypass.
+type JWKSFetcherStrategy interface {
+	Resolve(location string, ignoreCache bool) (*jose.JSONWebKeySet, error)
+}
@@ -25,11 +25,13 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/ory/x/errorsx"
@@ -41,12 +43,106 @@ import (
 
 const clientAssertionJWTBearerType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
 
+// Subject Alternative Name types a client may register for RFC 8705 mutual
+// TLS client authentication, in addition to the DN Subject field already
+// supported via GetCertificateSubjectFieldName/GetCertificateSubjectValue.
+const (
+	SANDNSField   = "dns"
+	SANURIField   = "uri"
+	SANEmailField = "email"
+	SANIPField    = "ip"
+)
+
+// CertificateSANClient is implemented by clients that wish to be identified
+// via a Subject Alternative Name field of their registered TLS client
+// certificate, rather than by the certificate's Subject DN: when
+// GetCertificateSANType is non-empty, only the SAN is matched and the
+// Subject DN is not checked.
+// It is declared as a separate, optional interface - following the same
+// pattern as OpenIDConnectClient - so that existing Client implementations
+// which only support DN matching keep working unchanged.
+type CertificateSANClient interface {
+	// GetCertificateSANType returns the SAN field the client wants to be
+	// matched against, one of SANDNSField, SANURIField, SANEmailField or
+	// SANIPField.
+	GetCertificateSANType() string
+
+	// GetCertificateSANValue returns the expected value of the SAN field
+	// named by GetCertificateSANType.
+	GetCertificateSANValue() string
+}
+
+// hmacAssertionSigningAlgorithms is the allowlist of signing algorithms a
+// 'client_secret_jwt' client assertion may use. Anything else - even if
+// jwt-go were to register a new *jwt.SigningMethodHMAC variant in the
+// future - is rejected outright.
+var hmacAssertionSigningAlgorithms = map[string]bool{
+	"HS256": true,
+	"HS384": true,
+	"HS512": true,
+}
+
+// HMACClientSecretClient is implemented by clients that wish to support the
+// 'client_secret_jwt' authentication method. Unlike GetHashedSecret, which
+// exists to support a one-way comparison of 'client_secret_basic' /
+// 'client_secret_post' credentials, verifying a client_secret_jwt HMAC
+// signature requires the plain-text secret, so a store that wants to
+// support this method must be able to recover it (or maintain a dedicated
+// HMAC key alongside the hashed secret). It is kept separate from and
+// optional on top of Client so that stores which only ever hash the secret
+// aren't forced to expose it just to satisfy the interface.
+type HMACClientSecretClient interface {
+	// GetClientSecretPlain returns the client's plain-text secret (or a
+	// dedicated HMAC key) used to verify a 'client_secret_jwt' assertion.
+	GetClientSecretPlain() []byte
+}
+
+// findClientHMACSecret resolves the HMAC key used to verify a
+// 'client_secret_jwt' client assertion, per RFC 7523 / OpenID Connect Core
+// section 9.
+func (f *Fosite) findClientHMACSecret(oidcClient OpenIDConnectClient, t *jwt.Token) (interface{}, error) {
+	alg, _ := t.Header["alg"].(string)
+	if !hmacAssertionSigningAlgorithms[alg] {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' uses signing algorithm '%s' which is not an allowed HMAC algorithm for 'client_secret_jwt'.", alg))
+	}
+
+	secretClient, ok := oidcClient.(HMACClientSecretClient)
+	if !ok {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This authorization server does not support client authentication method 'client_secret_jwt' for this OAuth 2.0 Client, because its store does not expose a recoverable client secret."))
+	}
+
+	secret := secretClient.GetClientSecretPlain()
+	if len(secret) == 0 {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client has no client secret registered, but one is required to use 'client_secret_jwt'."))
+	}
+
+	return secret, nil
+}
+
 func (f *Fosite) findClientPublicJWK(oidcClient OpenIDConnectClient, t *jwt.Token, expectsRSAKey bool) (interface{}, error) {
 	if set := oidcClient.GetJSONWebKeys(); set != nil {
 		return findPublicKey(t, set, expectsRSAKey)
 	}
 
 	if location := oidcClient.GetJSONWebKeysURI(); len(location) > 0 {
+		if f.JWKSManager != nil {
+			if keys, ok := f.JWKSManager.Keys(location); ok {
+				if key, err := findPublicKey(t, keys, expectsRSAKey); err == nil {
+					return key, nil
+				}
+
+				// The key we need might just have been rolled; force an
+				// (debounced) out-of-band sync and retry before falling
+				// back to the synchronous fetch below.
+				f.JWKSManager.ForceRefresh(location)
+				if keys, ok := f.JWKSManager.Keys(location); ok {
+					if key, err := findPublicKey(t, keys, expectsRSAKey); err == nil {
+						return key, nil
+					}
+				}
+			}
+		}
+
 		keys, err := f.JWKSFetcherStrategy.Resolve(location, false)
 		if err != nil {
 			return nil, err
@@ -67,11 +163,63 @@ func (f *Fosite) findClientPublicJWK(oidcClient OpenIDConnectClient, t *jwt.Toke
 	return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client has no JSON Web Keys set registered, but they are needed to complete the request."))
 }
 
+// AuthenticateClient authenticates the OAuth 2.0 Client making the request,
+// using the first of f.ClientAuthenticators (or the built-in defaults, if
+// none are configured) that is applicable to and claims the request. See
+// ClientAuthenticator for the selection rules.
+//
+// If ctx carries a Session (see ContextWithSession), the
+// MTLSClientAuthenticator tier uses it to record a successful mTLS
+// authentication's certificate-bound-access-token binding via
+// BindClientCertificate; callers that don't support RFC 8705 certificate
+// binding can simply pass ctx through unchanged.
 func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form url.Values) (Client, error) {
+	authenticators := f.ClientAuthenticators
+	if len(authenticators) == 0 {
+		authenticators = f.defaultClientAuthenticators()
+	}
+
+	ctx = contextWithResolvedClientCache(ctx)
+	assertionType := form.Get("client_assertion_type")
+	useMTLS := f.shouldAttemptMTLS(ctx, r, form)
+
+	for _, authenticator := range authenticators {
+		switch authenticator.Kind() {
+		case AssertionClientAuthenticator:
+			if assertionType == "" {
+				continue
+			}
+		case MTLSClientAuthenticator:
+			if assertionType != "" || !useMTLS {
+				continue
+			}
+		case CredentialClientAuthenticator:
+			if assertionType != "" || useMTLS {
+				continue
+			}
+		}
+
+		client, matched, err := authenticator.Authenticate(ctx, r, form)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return client, nil
+		}
+	}
+
+	return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This request could not be authenticated with any of the client authentication methods configured for this authorization server."))
+}
+
+// authenticateClientWithAssertion implements the 'private_key_jwt' and
+// 'client_secret_jwt' client authentication methods, both of which present
+// the client's credentials as a signed JWT in the 'client_assertion'
+// request parameter.
+func (f *Fosite) authenticateClientWithAssertion(ctx context.Context, r *http.Request, form url.Values) (Client, bool, error) {
 	if assertionType := form.Get("client_assertion_type"); assertionType == clientAssertionJWTBearerType {
 		assertion := form.Get("client_assertion")
 		if len(assertion) == 0 {
-			return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("The client_assertion request parameter must be set when using client_assertion_type of '%s'.", clientAssertionJWTBearerType))
+			return nil, true, errorsx.WithStack(ErrInvalidRequest.WithHintf("The client_assertion request parameter must be set when using client_assertion_type of '%s'.", clientAssertionJWTBearerType))
 		}
 
 		var clientID string
@@ -107,14 +255,14 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			switch oidcClient.GetTokenEndpointAuthMethod() {
 			case "private_key_jwt":
 				break
+			case "client_secret_jwt":
+				break
 			case "none":
 				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This requested OAuth 2.0 client does not support client authentication, however 'client_assertion' was provided in the request."))
 			case "client_secret_post":
 				fallthrough
 			case "client_secret_basic":
 				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however 'client_assertion' was provided in the request.", oidcClient.GetTokenEndpointAuthMethod()))
-			case "client_secret_jwt":
-				fallthrough
 			default:
 				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however that method is not supported by this server.", oidcClient.GetTokenEndpointAuthMethod()))
 			}
@@ -130,7 +278,11 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			} else if _, ok := t.Method.(*jwt.SigningMethodRSAPSS); ok {
 				return f.findClientPublicJWK(oidcClient, t, true)
 			} else if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("This authorization server does not support client authentication method 'client_secret_jwt'."))
+				if oidcClient.GetTokenEndpointAuthMethod() != "client_secret_jwt" {
+					return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("This requested OAuth 2.0 client only supports client authentication method '%s', however the 'client_assertion' was signed with a HMAC based algorithm which requires 'client_secret_jwt'.", oidcClient.GetTokenEndpointAuthMethod()))
+				}
+
+				return f.findClientHMACSecret(oidcClient, t)
 			}
 
 			return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' request parameter uses unsupported signing algorithm '%s'.", t.Header["alg"]))
@@ -140,31 +292,31 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			var e *jwt.ValidationError
 			if errors.As(err, &e) {
 				if e.Inner != nil {
-					return nil, e.Inner
+					return nil, true, e.Inner
 				}
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
+				return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
 			}
-			return nil, err
+			return nil, true, err
 		} else if err := token.Claims.Valid(); err != nil {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the request object because its claims could not be validated, check if the expiry time is set correctly.").WithWrap(err).WithDebug(err.Error()))
+			return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the request object because its claims could not be validated, check if the expiry time is set correctly.").WithWrap(err).WithDebug(err.Error()))
 		}
 
 		claims, ok := token.Claims.(*jwt.MapClaims)
 		if !ok {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to type assert claims from request parameter 'client_assertion'.").WithDebugf("Got claims of type %T but expected type '*jwt.MapClaims'.", token.Claims))
+			return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to type assert claims from request parameter 'client_assertion'.").WithDebugf("Got claims of type %T but expected type '*jwt.MapClaims'.", token.Claims))
 		}
 
 		var jti string
 		if !claims.VerifyIssuer(clientID, true) {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+			return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'iss' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
 		} else if f.TokenURL == "" {
-			return nil, errorsx.WithStack(ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
+			return nil, true, errorsx.WithStack(ErrMisconfiguration.WithHint("The authorization server's token endpoint URL has not been set."))
 		} else if sub, ok := (*claims)["sub"].(string); !ok || sub != clientID {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
+			return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'sub' from 'client_assertion' must match the 'client_id' of the OAuth 2.0 Client."))
 		} else if jti, ok = (*claims)["jti"].(string); !ok || len(jti) == 0 {
-			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
+			return nil, true, errorsx.WithStack(ErrInvalidClient.WithHint("Claim 'jti' from 'client_assertion' must be set but is not."))
 		} else if f.Store.ClientAssertionJWTValid(ctx, jti) != nil {
-			return nil, errorsx.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
+			return nil, true, errorsx.WithStack(ErrJTIKnown.WithHint("Claim 'jti' from 'client_assertion' MUST only be used once."))
 		}
 
 		// type conversion according to jwt.MapClaims.VerifyExpiresAt
@@ -180,15 +332,15 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 		}
 
 		if err != nil {
-			return nil, errorsx.WithStack(err)
+			return nil, true, errorsx.WithStack(err)
 		}
 		if err := f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0)); err != nil {
-			return nil, err
+			return nil, true, err
 		}
 
 		if auds, ok := (*claims)["aud"].([]interface{}); !ok {
 			if !claims.VerifyAudience(f.TokenURL, true) {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
+				return nil, true, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
 			}
 		} else {
 			var found bool
@@ -200,90 +352,115 @@ func (f *Fosite) AuthenticateClient(ctx context.Context, r *http.Request, form u
 			}
 
 			if !found {
-				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
+				return nil, true, errorsx.WithStack(ErrInvalidClient.WithHintf("Claim 'audience' from 'client_assertion' must match the authorization server's token endpoint '%s'.", f.TokenURL))
 			}
 		}
 
-		return client, nil
+		return client, true, nil
 	} else if len(assertionType) > 0 {
-		return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("Unknown client_assertion_type '%s'.", assertionType))
+		return nil, true, errorsx.WithStack(ErrInvalidRequest.WithHintf("Unknown client_assertion_type '%s'.", assertionType))
 	}
 
-	// TODO: validate that client and server were configured to accept tls auth
-	if ok := isTLSAuth(r, form); ok {
-		return f.authenticateClientWithTLS(ctx, r, form)
-	}
+	return nil, false, nil
+}
 
+// authenticateClientWithCredentials implements the 'client_secret_basic',
+// 'client_secret_post' and 'none' client authentication methods, i.e.
+// everything that is neither an assertion (RFC 7523) nor mTLS (RFC 8705)
+// based. It is the fallback authenticator: unlike its siblings, it always
+// claims the request once invoked.
+func (f *Fosite) authenticateClientWithCredentials(ctx context.Context, r *http.Request, form url.Values) (Client, bool, error) {
 	clientID, clientSecret, err := clientCredentialsFromRequest(r, form)
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
 
 	client, err := f.Store.GetClient(ctx, clientID)
 	if err != nil {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
+		return nil, true, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
 	}
 
 	if oidcClient, ok := client.(OpenIDConnectClient); !ok {
 		// If this isn't an OpenID Connect client then we actually don't care about any of this, just continue!
 	} else if ok && form.Get("client_id") != "" && form.Get("client_secret") != "" && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_post" {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_post' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_post'.", oidcClient.GetTokenEndpointAuthMethod()))
+		return nil, true, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_post' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_post'.", oidcClient.GetTokenEndpointAuthMethod()))
 	} else if _, _, basicOk := r.BasicAuth(); basicOk && ok && oidcClient.GetTokenEndpointAuthMethod() != "client_secret_basic" {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_basic' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_basic'.", oidcClient.GetTokenEndpointAuthMethod()))
+		return nil, true, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'client_secret_basic' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'client_secret_basic'.", oidcClient.GetTokenEndpointAuthMethod()))
 	} else if ok && oidcClient.GetTokenEndpointAuthMethod() != "none" && client.IsPublic() {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'none' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'none'.", oidcClient.GetTokenEndpointAuthMethod()))
+		return nil, true, errorsx.WithStack(ErrInvalidClient.WithHintf("The OAuth 2.0 Client supports client authentication method '%s', but method 'none' was requested. You must configure the OAuth 2.0 client's 'token_endpoint_auth_method' value to accept 'none'.", oidcClient.GetTokenEndpointAuthMethod()))
 	}
 
 	if client.IsPublic() {
-		return client, nil
+		return client, true, nil
 	}
 
 	// Enforce client authentication
 	if err := f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(clientSecret)); err != nil {
-		return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
+		return nil, true, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
 	}
 
-	return client, nil
+	return client, true, nil
 }
 
+// authenticateClientWithTLS implements the 'tls_client_auth' and
+// 'self_signed_tls_client_auth' client authentication methods (RFC 8705
+// section 2). On success, it calls BindClientCertificate to record the
+// presented certificate's thumbprint onto the Session stored in ctx (see
+// ContextWithSession), so that an access/refresh token subsequently issued
+// for this request can be certificate-bound; see
+// CertificateBoundAccessTokensClient.
 func (f *Fosite) authenticateClientWithTLS(ctx context.Context, r *http.Request, form url.Values) (Client, error) {
+	session := sessionFromContext(ctx)
 	clientID := form.Get("client_id")
 	if len(clientID) == 0 {
 		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The client_id was not given"))
 	}
-	client, err := f.Store.GetClient(ctx, clientID)
+	client, err := f.getClient(ctx, clientID)
 	if err != nil {
 		return nil, err
 	}
 
+	// this is expected to exists, validation already in isTLS method
+	cert := r.TLS.PeerCertificates[0]
+
 	// TODO: validate if this validation makes sense,
 	// that is, a OpenIDConnectClient with tls auth
-	if oidcClient, ok := client.(OpenIDConnectClient); ok &&
-		oidcClient.GetTokenEndpointAuthMethod() != "tls_client_auth" {
-		return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf(
-			"This requested OAuth 2.0 client only supports client authentication method '%s', but TLS authentication was requested.", oidcClient.GetTokenEndpointAuthMethod()))
+	if oidcClient, ok := client.(OpenIDConnectClient); ok {
+		switch oidcClient.GetTokenEndpointAuthMethod() {
+		case "self_signed_tls_client_auth":
+			selfSignedClient, err := f.authenticateSelfSignedTLSClient(oidcClient, cert)
+			if err != nil {
+				return nil, err
+			}
+			BindClientCertificate(r, selfSignedClient, session)
+			return selfSignedClient, nil
+		case "tls_client_auth":
+			break
+		default:
+			return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf(
+				"This requested OAuth 2.0 client only supports client authentication method '%s', but TLS authentication was requested.", oidcClient.GetTokenEndpointAuthMethod()))
+		}
+	}
+
+	if sanClient, ok := client.(CertificateSANClient); ok && sanClient.GetCertificateSANType() != "" {
+		if err := matchCertificateSAN(cert, sanClient); err != nil {
+			return nil, err
+		}
+		BindClientCertificate(r, client, session)
+		return client, nil
 	}
 
-	// TODO: Support SAN Fields.
-	// Check this for impl. https://github.com/golang/go/blob/2a26f5809e4e80e7d8d4e20b9965efb2eefe71c5/src/crypto/x509/x509.go#L1439-L1456
-	// This first version only supports the DN Subject field
 	IDField := client.GetCertificateSubjectFieldName()
 	if IDField != DNField {
 		return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("Client certificate field not supported: %s", IDField))
 	}
 
-	// this is expected to exists, validation already in isTLS method
-	cert := r.TLS.PeerCertificates[0]
-
-	// TODO: Implement a stronger matching using a RDN Sequence instead
-	// of strings comparisons, which can be error prone or could
-	// provide false positives.
-	// For that the client certificate value must be parsed into
-	// a RDN Sequence based on https://www.ietf.org/rfc/rfc4514.txt,
-	// currently there is no a library, it must be by ourselfs.
-	// Then check if the parsed RDNs are contained in cert.Subject.Names
 	expStr := client.GetCertificateSubjectValue()
-	if !strings.Contains(cert.Subject.String(), expStr) {
+	matched, err := MatchRDNSequence(expStr, cert.Subject.ToRDNSequence())
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to parse the client's registered certificate subject as a RFC 4514 distinguished name.").WithWrap(err).WithDebug(err.Error()))
+	}
+	if !matched {
 		return nil, errorsx.WithStack(ErrInvalidRequest.
 			WithDebugf("Certificate does not contain expected subject. Given(%s), Expected(%s)",
 				cert.Subject.Names,
@@ -291,9 +468,106 @@ func (f *Fosite) authenticateClientWithTLS(ctx context.Context, r *http.Request,
 	}
 
 	// bingo!
+	BindClientCertificate(r, client, session)
 	return client, nil
 }
 
+// matchCertificateSAN checks that the presented certificate carries the
+// Subject Alternative Name the client registered via CertificateSANClient.
+// See https://github.com/golang/go/blob/2a26f5809e4e80e7d8d4e20b9965efb2eefe71c5/src/crypto/x509/x509.go#L1439-L1456
+// for the fields the standard library exposes per SAN type.
+func matchCertificateSAN(cert *x509.Certificate, client CertificateSANClient) error {
+	expected := client.GetCertificateSANValue()
+
+	switch sanType := client.GetCertificateSANType(); sanType {
+	case SANDNSField:
+		for _, name := range cert.DNSNames {
+			if name == expected {
+				return nil
+			}
+		}
+	case SANURIField:
+		for _, uri := range cert.URIs {
+			if uri.String() == expected {
+				return nil
+			}
+		}
+	case SANEmailField:
+		for _, email := range cert.EmailAddresses {
+			if email == expected {
+				return nil
+			}
+		}
+	case SANIPField:
+		for _, ip := range cert.IPAddresses {
+			if ip.String() == expected {
+				return nil
+			}
+		}
+	default:
+		return errorsx.WithStack(ErrInvalidClient.WithHintf("Client certificate SAN field not supported: %s", sanType))
+	}
+
+	return errorsx.WithStack(ErrInvalidRequest.WithHintf("Certificate does not contain expected SAN value '%s'.", expected))
+}
+
+// authenticateSelfSignedTLSClient implements RFC 8705 section 2.2: the
+// client authenticates by presenting a self-signed certificate whose
+// SHA-256 thumbprint matches a key published in its registered JWKS
+// (jwks or jwks_uri).
+func (f *Fosite) authenticateSelfSignedTLSClient(oidcClient OpenIDConnectClient, cert *x509.Certificate) (Client, error) {
+	thumbprint := certificateThumbprintSHA256(cert)
+
+	keys, err := f.resolveClientJSONWebKeys(oidcClient)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys.Keys {
+		if len(key.Certificates) == 0 {
+			continue
+		}
+
+		if certificateThumbprintSHA256(key.Certificates[0]) == thumbprint {
+			return oidcClient, nil
+		}
+	}
+
+	return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The presented client certificate does not match any key registered for this OAuth 2.0 Client's 'self_signed_tls_client_auth' method."))
+}
+
+// resolveClientJSONWebKeys returns the client's registered JSON Web Key Set,
+// preferring JWKSManager's background-synced cache over a synchronous
+// JWKSFetcherStrategy.Resolve call, the same way findClientPublicJWK does -
+// so that self_signed_tls_client_auth doesn't pay for a network round-trip
+// on every handshake either.
+func (f *Fosite) resolveClientJSONWebKeys(oidcClient OpenIDConnectClient) (*jose.JSONWebKeySet, error) {
+	if set := oidcClient.GetJSONWebKeys(); set != nil {
+		return set, nil
+	}
+
+	location := oidcClient.GetJSONWebKeysURI()
+	if len(location) == 0 {
+		return nil, errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client has no JSON Web Keys set registered, but they are needed to complete the request."))
+	}
+
+	if f.JWKSManager != nil {
+		if keys, ok := f.JWKSManager.Keys(location); ok {
+			return keys, nil
+		}
+	}
+
+	return f.JWKSFetcherStrategy.Resolve(location, false)
+}
+
+// certificateThumbprintSHA256 computes the base64url-encoded (no padding)
+// SHA-256 thumbprint of a certificate's DER encoding, as used by the
+// 'x5t#S256' confirmation method in RFC 8705.
+func certificateThumbprintSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
 func isTLSAuth(r *http.Request, form url.Values) bool {
 	// This implementation expects the client certificate in
 	// r.TLS.PeerCertificates[0], which in a conventional mTLS,
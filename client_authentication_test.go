@@ -0,0 +1,239 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// oidcClientFixture is a minimal OpenIDConnectClient fixture shared by the
+// tests in this package that need a resolvable client but don't care about
+// most of its fields - embed it and override what the test actually
+// exercises.
+type oidcClientFixture struct {
+	id                      string
+	tokenEndpointAuthMethod string
+}
+
+func (c oidcClientFixture) GetID() string                                { return c.id }
+func (c oidcClientFixture) GetHashedSecret() []byte                      { return nil }
+func (c oidcClientFixture) IsPublic() bool                               { return false }
+func (c oidcClientFixture) GetCertificateSubjectFieldName() string       { return "" }
+func (c oidcClientFixture) GetCertificateSubjectValue() string           { return "" }
+func (c oidcClientFixture) GetJSONWebKeys() *jose.JSONWebKeySet          { return nil }
+func (c oidcClientFixture) GetJSONWebKeysURI() string                    { return "" }
+func (c oidcClientFixture) GetTokenEndpointAuthMethod() string           { return c.tokenEndpointAuthMethod }
+func (c oidcClientFixture) GetTokenEndpointAuthSigningAlgorithm() string { return "" }
+
+type sanClient struct {
+	sanType  string
+	sanValue string
+}
+
+func (c sanClient) GetCertificateSANType() string  { return c.sanType }
+func (c sanClient) GetCertificateSANValue() string { return c.sanValue }
+
+func mustCertWithSANs(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	uri, err := url.Parse("https://client.example.com/path")
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "client.example.com"},
+		NotBefore:      time.Now(),
+		NotAfter:       time.Now().Add(time.Hour),
+		DNSNames:       []string{"client.example.com"},
+		EmailAddresses: []string{"client@example.com"},
+		URIs:           []*url.URL{uri},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestMatchCertificateSAN(t *testing.T) {
+	cert := mustCertWithSANs(t)
+
+	t.Run("matches a registered DNS SAN", func(t *testing.T) {
+		err := matchCertificateSAN(cert, sanClient{sanType: SANDNSField, sanValue: "client.example.com"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("matches a registered URI SAN", func(t *testing.T) {
+		err := matchCertificateSAN(cert, sanClient{sanType: SANURIField, sanValue: "https://client.example.com/path"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("matches a registered email SAN", func(t *testing.T) {
+		err := matchCertificateSAN(cert, sanClient{sanType: SANEmailField, sanValue: "client@example.com"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails when the DNS SAN value is not present", func(t *testing.T) {
+		err := matchCertificateSAN(cert, sanClient{sanType: SANDNSField, sanValue: "other.example.com"})
+		assert.Error(t, err)
+	})
+
+	t.Run("fails on an unsupported SAN type", func(t *testing.T) {
+		err := matchCertificateSAN(cert, sanClient{sanType: "upn", sanValue: "whatever"})
+		assert.Error(t, err)
+	})
+}
+
+// tlsClientFixture is a Client/OpenIDConnectClient/CertificateSANClient
+// fixture covering every field authenticateClientWithTLS and
+// authenticateSelfSignedTLSClient consult: the registered auth method, an
+// optional SAN for tls_client_auth, and an optional JWKS for
+// self_signed_tls_client_auth.
+type tlsClientFixture struct {
+	oidcClientFixture
+
+	sanType  string
+	sanValue string
+	jwks     *jose.JSONWebKeySet
+}
+
+func (c tlsClientFixture) GetCertificateSANType() string       { return c.sanType }
+func (c tlsClientFixture) GetCertificateSANValue() string      { return c.sanValue }
+func (c tlsClientFixture) GetJSONWebKeys() *jose.JSONWebKeySet { return c.jwks }
+
+func requestWithPeerCertificate(cert *x509.Certificate) *http.Request {
+	return &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}}
+}
+
+func jwksWithCertificate(cert *x509.Certificate) *jose.JSONWebKeySet {
+	return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-1", Certificates: []*x509.Certificate{cert}}}}
+}
+
+func TestAuthenticateSelfSignedTLSClient(t *testing.T) {
+	cert := mustCertWithSANs(t)
+
+	t.Run("matches a certificate whose thumbprint is registered in the client's JWKS", func(t *testing.T) {
+		f := &Fosite{}
+		client := tlsClientFixture{jwks: jwksWithCertificate(cert)}
+
+		got, err := f.authenticateSelfSignedTLSClient(client, cert)
+		require.NoError(t, err)
+		assert.Equal(t, client, got)
+	})
+
+	t.Run("rejects a certificate absent from the client's JWKS", func(t *testing.T) {
+		f := &Fosite{}
+		other := mustCertWithSANs(t)
+		client := tlsClientFixture{jwks: jwksWithCertificate(other)}
+
+		_, err := f.authenticateSelfSignedTLSClient(client, cert)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a client with no registered JWKS", func(t *testing.T) {
+		f := &Fosite{}
+		client := tlsClientFixture{}
+
+		_, err := f.authenticateSelfSignedTLSClient(client, cert)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthenticateClientWithTLS(t *testing.T) {
+	cert := mustCertWithSANs(t)
+	form := url.Values{"client_id": {"client-1"}}
+
+	t.Run("routes self_signed_tls_client_auth through the JWKS thumbprint match", func(t *testing.T) {
+		client := &tlsClientFixture{
+			oidcClientFixture: oidcClientFixture{id: "client-1", tokenEndpointAuthMethod: "self_signed_tls_client_auth"},
+			jwks:              jwksWithCertificate(cert),
+		}
+		f := &Fosite{Store: &countingClientStore{client: client}}
+
+		got, err := f.authenticateClientWithTLS(context.Background(), requestWithPeerCertificate(cert), form)
+		require.NoError(t, err)
+		assert.Equal(t, client, got)
+	})
+
+	t.Run("rejects self_signed_tls_client_auth when the certificate isn't registered", func(t *testing.T) {
+		client := &tlsClientFixture{
+			oidcClientFixture: oidcClientFixture{id: "client-1", tokenEndpointAuthMethod: "self_signed_tls_client_auth"},
+			jwks:              jwksWithCertificate(mustCertWithSANs(t)),
+		}
+		f := &Fosite{Store: &countingClientStore{client: client}}
+
+		_, err := f.authenticateClientWithTLS(context.Background(), requestWithPeerCertificate(cert), form)
+		assert.Error(t, err)
+	})
+
+	t.Run("routes tls_client_auth through SAN matching instead of the JWKS", func(t *testing.T) {
+		client := &tlsClientFixture{
+			oidcClientFixture: oidcClientFixture{id: "client-1", tokenEndpointAuthMethod: "tls_client_auth"},
+			sanType:           SANDNSField,
+			sanValue:          "client.example.com",
+		}
+		f := &Fosite{Store: &countingClientStore{client: client}}
+
+		got, err := f.authenticateClientWithTLS(context.Background(), requestWithPeerCertificate(cert), form)
+		require.NoError(t, err)
+		assert.Equal(t, client, got)
+	})
+
+	t.Run("rejects a client whose registered method isn't a TLS method", func(t *testing.T) {
+		client := &tlsClientFixture{
+			oidcClientFixture: oidcClientFixture{id: "client-1", tokenEndpointAuthMethod: "client_secret_basic"},
+		}
+		f := &Fosite{Store: &countingClientStore{client: client}}
+
+		_, err := f.authenticateClientWithTLS(context.Background(), requestWithPeerCertificate(cert), form)
+		assert.Error(t, err)
+	})
+}
+
+func TestCertificateThumbprintSHA256(t *testing.T) {
+	certA := mustCertWithSANs(t)
+	certB := mustCertWithSANs(t)
+
+	assert.NotEmpty(t, certificateThumbprintSHA256(certA))
+	assert.Equal(t, certificateThumbprintSHA256(certA), certificateThumbprintSHA256(certA))
+	assert.NotEqual(t, certificateThumbprintSHA256(certA), certificateThumbprintSHA256(certB))
+}
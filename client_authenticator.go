@@ -0,0 +1,245 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ClientAuthenticatorKind groups a ClientAuthenticator by the kind of
+// credential it authenticates, so that Fosite.AuthenticateClient can apply
+// RFC 8705 / OpenID Connect Core's client authentication method precedence
+// without every authenticator having to re-implement the same selection
+// logic.
+type ClientAuthenticatorKind int
+
+const (
+	// AssertionClientAuthenticator authenticates a 'client_assertion'
+	// request parameter (private_key_jwt, client_secret_jwt). It is tried
+	// whenever 'client_assertion_type' is set, to the exclusion of every
+	// other kind.
+	AssertionClientAuthenticator ClientAuthenticatorKind = iota
+
+	// MTLSClientAuthenticator authenticates using the TLS client
+	// certificate presented on the connection (tls_client_auth,
+	// self_signed_tls_client_auth). It is tried when 'client_assertion_type'
+	// is not set and shouldAttemptMTLS reports true (a client certificate
+	// is present and the client is registered for an mTLS method), to the
+	// exclusion of the credential kind.
+	MTLSClientAuthenticator
+
+	// CredentialClientAuthenticator authenticates using the credentials
+	// carried in the request body or HTTP Authorization header
+	// (client_secret_basic, client_secret_post, none). It is tried when
+	// neither of the above apply.
+	CredentialClientAuthenticator
+)
+
+// ClientAuthenticator implements a single OAuth 2.0 / OpenID Connect client
+// authentication method. Fosite.AuthenticateClient tries every registered
+// ClientAuthenticator whose Kind() is applicable to the incoming request,
+// in order, and uses the first one that reports matched = true.
+//
+// Authenticate must return matched = false (with a nil error) if the
+// request simply doesn't carry the credential this authenticator looks
+// for - that is not an authentication failure, it just means the next
+// authenticator of the same kind should be given a chance. Once an
+// authenticator recognizes its credential, it must return matched = true,
+// even if verifying that credential then fails; a failure at that point is
+// a genuine authentication error and must not fall through to a different
+// method.
+type ClientAuthenticator interface {
+	// Kind reports which precedence group this authenticator belongs to.
+	Kind() ClientAuthenticatorKind
+
+	// Authenticate attempts to authenticate the client making the request.
+	// If ctx carries a Session (see ContextWithSession), an authenticator
+	// which supports RFC 8705 certificate-bound access tokens (currently
+	// only the mTLS tier) uses it to record the binding via
+	// BindClientCertificate; authenticators that don't need it simply
+	// ignore it.
+	Authenticate(ctx context.Context, r *http.Request, form url.Values) (client Client, matched bool, err error)
+}
+
+// defaultClientAuthenticators returns the built-in ClientAuthenticator set
+// used by Fosite.AuthenticateClient when Fosite.ClientAuthenticators is
+// empty: client_secret_basic, client_secret_post and none via HTTP
+// credentials; private_key_jwt and client_secret_jwt via client assertion;
+// tls_client_auth and self_signed_tls_client_auth via mTLS.
+//
+// Downstream projects that want to add a custom method (e.g. a DPoP-bound
+// assertion, or FAPI-CIBA) can call DefaultClientAuthenticators, append
+// their own ClientAuthenticator, and assign the result to
+// Fosite.ClientAuthenticators instead of forking this package.
+func (f *Fosite) defaultClientAuthenticators() []ClientAuthenticator {
+	return []ClientAuthenticator{
+		&assertionClientAuthenticator{f},
+		&mtlsClientAuthenticator{f},
+		&credentialClientAuthenticator{f},
+	}
+}
+
+// DefaultClientAuthenticators returns the built-in ClientAuthenticator set
+// (see defaultClientAuthenticators) for code outside this package to extend.
+func (f *Fosite) DefaultClientAuthenticators() []ClientAuthenticator {
+	return f.defaultClientAuthenticators()
+}
+
+// assertionClientAuthenticator adapts Fosite.authenticateClientWithAssertion
+// (private_key_jwt, client_secret_jwt) to the ClientAuthenticator interface.
+type assertionClientAuthenticator struct {
+	f *Fosite
+}
+
+func (*assertionClientAuthenticator) Kind() ClientAuthenticatorKind {
+	return AssertionClientAuthenticator
+}
+
+func (a *assertionClientAuthenticator) Authenticate(ctx context.Context, r *http.Request, form url.Values) (Client, bool, error) {
+	return a.f.authenticateClientWithAssertion(ctx, r, form)
+}
+
+// mtlsClientAuthenticator adapts Fosite.authenticateClientWithTLS
+// (tls_client_auth, self_signed_tls_client_auth) to the ClientAuthenticator
+// interface.
+type mtlsClientAuthenticator struct {
+	f *Fosite
+}
+
+func (*mtlsClientAuthenticator) Kind() ClientAuthenticatorKind {
+	return MTLSClientAuthenticator
+}
+
+func (a *mtlsClientAuthenticator) Authenticate(ctx context.Context, r *http.Request, form url.Values) (Client, bool, error) {
+	if !a.f.shouldAttemptMTLS(ctx, r, form) {
+		return nil, false, nil
+	}
+
+	client, err := a.f.authenticateClientWithTLS(ctx, r, form)
+	return client, true, err
+}
+
+// credentialClientAuthenticator adapts
+// Fosite.authenticateClientWithCredentials (client_secret_basic,
+// client_secret_post, none) to the ClientAuthenticator interface.
+type credentialClientAuthenticator struct {
+	f *Fosite
+}
+
+func (*credentialClientAuthenticator) Kind() ClientAuthenticatorKind {
+	return CredentialClientAuthenticator
+}
+
+func (a *credentialClientAuthenticator) Authenticate(ctx context.Context, r *http.Request, form url.Values) (Client, bool, error) {
+	return a.f.authenticateClientWithCredentials(ctx, r, form)
+}
+
+// resolvedClientContextKey is the context.Context key AuthenticateClient
+// caches a resolved Client under, keyed by client_id, so that
+// shouldAttemptMTLS and authenticateClientWithTLS - which both need to
+// resolve the same client_id when routing a request to the mTLS tier -
+// share a single f.Store.GetClient call instead of each paying for their
+// own.
+type resolvedClientContextKey struct{}
+
+// resolvedClient is the mutable cache entry stored under
+// resolvedClientContextKey. It's filled in by whichever of
+// shouldAttemptMTLS / authenticateClientWithTLS resolves a client_id
+// first; later callers sharing the same ctx reuse it.
+type resolvedClient struct {
+	id     string
+	client Client
+	err    error
+	filled bool
+}
+
+// contextWithResolvedClientCache returns a copy of ctx carrying an empty
+// resolvedClient cache, so that the GetClient calls made while routing and
+// then authenticating a single request can be deduplicated. See
+// resolvedClientContextKey.
+func contextWithResolvedClientCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resolvedClientContextKey{}, &resolvedClient{})
+}
+
+// getClient resolves clientID via f.Store.GetClient, reusing the result
+// already cached in ctx for the same clientID if one is present - see
+// resolvedClientContextKey. If ctx doesn't carry a cache (e.g. a
+// ClientAuthenticator invoked outside of AuthenticateClient's dispatch
+// loop), it falls back to calling f.Store.GetClient directly every time.
+func (f *Fosite) getClient(ctx context.Context, clientID string) (Client, error) {
+	cache, ok := ctx.Value(resolvedClientContextKey{}).(*resolvedClient)
+	if !ok {
+		return f.Store.GetClient(ctx, clientID)
+	}
+
+	if cache.filled && cache.id == clientID {
+		return cache.client, cache.err
+	}
+
+	client, err := f.Store.GetClient(ctx, clientID)
+	cache.id, cache.client, cache.err, cache.filled = clientID, client, err, true
+	return client, err
+}
+
+// shouldAttemptMTLS reports whether the request should be routed to the
+// MTLSClientAuthenticator tier: a TLS client certificate must be present,
+// and - if the client_id can be resolved to a client that declares a
+// token_endpoint_auth_method - that method must be one of the mTLS methods.
+// A client whose registered method is e.g. 'client_secret_basic' must fall
+// through to credential-based auth even when it happens to terminate on an
+// mTLS-enabled listener, rather than being hard-routed into a TLS-only
+// auth error.
+//
+// A client that cannot be resolved at all (unknown client_id, store error)
+// or that doesn't declare an OpenID Connect auth method is still routed to
+// mTLS, so that authenticateClientWithTLS can produce its usual, specific
+// error for that case instead of silently falling through to credential
+// auth with a less helpful one.
+func (f *Fosite) shouldAttemptMTLS(ctx context.Context, r *http.Request, form url.Values) bool {
+	if !isTLSAuth(r, form) {
+		return false
+	}
+
+	clientID := form.Get("client_id")
+	if clientID == "" {
+		return true
+	}
+
+	client, err := f.getClient(ctx, clientID)
+	if err != nil {
+		return true
+	}
+
+	oidcClient, ok := client.(OpenIDConnectClient)
+	if !ok {
+		return true
+	}
+
+	switch oidcClient.GetTokenEndpointAuthMethod() {
+	case "tls_client_auth", "self_signed_tls_client_auth":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingClientStore is a ClientManager fixture that counts GetClient
+// calls, so tests can assert that a resolved client is reused rather than
+// re-fetched from the store.
+type countingClientStore struct {
+	client Client
+	calls  int
+}
+
+func (s *countingClientStore) GetClient(ctx context.Context, id string) (Client, error) {
+	s.calls++
+	return s.client, nil
+}
+
+func (s *countingClientStore) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	return nil
+}
+
+func (s *countingClientStore) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	return nil
+}
+
+func TestDefaultClientAuthenticators(t *testing.T) {
+	f := &Fosite{}
+	authenticators := f.defaultClientAuthenticators()
+
+	require.Len(t, authenticators, 3)
+	assert.Equal(t, AssertionClientAuthenticator, authenticators[0].Kind(), "assertion must be tried before mTLS and credentials")
+	assert.Equal(t, MTLSClientAuthenticator, authenticators[1].Kind(), "mTLS must be tried before credentials")
+	assert.Equal(t, CredentialClientAuthenticator, authenticators[2].Kind())
+}
+
+func TestGetClientReusesResolvedClientCache(t *testing.T) {
+	store := &countingClientStore{client: &oidcClientFixture{id: "some-client"}}
+	f := &Fosite{Store: store}
+
+	t.Run("without a cache in ctx, every call hits the store", func(t *testing.T) {
+		store.calls = 0
+
+		_, err := f.getClient(context.Background(), "some-client")
+		require.NoError(t, err)
+		_, err = f.getClient(context.Background(), "some-client")
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, store.calls)
+	})
+
+	t.Run("with a cache in ctx, repeat calls for the same client_id are deduplicated", func(t *testing.T) {
+		store.calls = 0
+		ctx := contextWithResolvedClientCache(context.Background())
+
+		_, err := f.getClient(ctx, "some-client")
+		require.NoError(t, err)
+		_, err = f.getClient(ctx, "some-client")
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, store.calls)
+	})
+}
+
+func TestShouldAttemptMTLSWithoutPeerCertificate(t *testing.T) {
+	f := &Fosite{}
+
+	r, err := http.NewRequest("POST", "https://example.com/token", nil)
+	require.NoError(t, err)
+
+	form := url.Values{"client_id": {"some-client"}}
+	assert.False(t, f.shouldAttemptMTLS(context.Background(), r, form), "a request with no TLS peer certificate must never be routed to mTLS")
+}
+
+func TestShouldAttemptMTLSWithNonMTLSClient(t *testing.T) {
+	client := &oidcClientFixture{id: "some-client", tokenEndpointAuthMethod: "client_secret_basic"}
+	f := &Fosite{Store: &countingClientStore{client: client}}
+
+	r := &http.Request{TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}}
+	form := url.Values{"client_id": {"some-client"}}
+
+	assert.False(t, f.shouldAttemptMTLS(context.Background(), r, form),
+		"a resolved client registered for a non-mTLS auth method must fall through to credential auth even with a peer certificate present")
+}
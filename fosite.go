@@ -0,0 +1,70 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+// Fosite is the main implementation of OAuth2Provider. Only fields touched
+// by this package's client authentication code are declared here; the rest
+// of the authorization/token/introspection/revocation surface lives
+// alongside it.
+type Fosite struct {
+	// Store persists and retrieves clients, authorize/access request state
+	// and replay-protection markers (e.g. seen client_assertion jti values).
+	Store ClientManager
+
+	// Hasher is used to compare a presented 'client_secret_basic' /
+	// 'client_secret_post' secret against the client's hashed secret.
+	Hasher Hasher
+
+	// TokenURL is this authorization server's token endpoint URL. It is the
+	// expected 'aud' claim of private_key_jwt / client_secret_jwt client
+	// assertions.
+	TokenURL string
+
+	// JWKSFetcherStrategy resolves a client's jwks_uri into a JSON Web Key
+	// Set, synchronously, with an optional forced cache bypass.
+	JWKSFetcherStrategy JWKSFetcherStrategy
+
+	// JWKSManager, if set, is consulted by findClientPublicJWK and
+	// resolveClientJSONWebKeys before falling back to a synchronous
+	// JWKSFetcherStrategy.Resolve call, so that private_key_jwt /
+	// self_signed_tls_client_auth authentications against a jwks_uri are
+	// served from a background-synced cache rather than
+	// paying for a network round-trip on every request. Call Close when the
+	// Fosite instance is no longer needed to stop its sync goroutines.
+	JWKSManager *JWKSManager
+
+	// ClientAuthenticators, if non-empty, overrides the built-in client
+	// authentication methods tried by AuthenticateClient. See
+	// ClientAuthenticator and defaultClientAuthenticators.
+	ClientAuthenticators []ClientAuthenticator
+}
+
+// Close releases background resources held by this Fosite instance, such as
+// JWKSManager's per-jwks_uri sync goroutines. It is safe to call Close more
+// than once, and safe to call on a zero-value Fosite.
+func (f *Fosite) Close() error {
+	if f.JWKSManager == nil {
+		return nil
+	}
+
+	return f.JWKSManager.Close()
+}
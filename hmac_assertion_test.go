@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACAssertionSigningAlgorithms(t *testing.T) {
+	assert.True(t, hmacAssertionSigningAlgorithms["HS256"])
+	assert.True(t, hmacAssertionSigningAlgorithms["HS384"])
+	assert.True(t, hmacAssertionSigningAlgorithms["HS512"])
+
+	assert.False(t, hmacAssertionSigningAlgorithms["RS256"], "RSA algorithms must never be accepted for client_secret_jwt")
+	assert.False(t, hmacAssertionSigningAlgorithms["none"], "the 'none' algorithm must never be accepted for client_secret_jwt")
+	assert.False(t, hmacAssertionSigningAlgorithms[""])
+}
+
+func hs256Token() *jwt.Token {
+	return &jwt.Token{Header: map[string]interface{}{"alg": "HS256"}}
+}
+
+func TestFindClientHMACSecret(t *testing.T) {
+	f := &Fosite{}
+
+	t.Run("rejects a disallowed signing algorithm", func(t *testing.T) {
+		oidcClient := &hmacSecretClientFixture{secret: []byte("super-secret")}
+		token := &jwt.Token{Header: map[string]interface{}{"alg": "RS256"}}
+
+		_, err := f.findClientHMACSecret(oidcClient, token)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a client whose store doesn't expose HMACClientSecretClient", func(t *testing.T) {
+		_, err := f.findClientHMACSecret(&oidcClientFixture{}, hs256Token())
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a client with no secret registered", func(t *testing.T) {
+		oidcClient := &hmacSecretClientFixture{}
+
+		_, err := f.findClientHMACSecret(oidcClient, hs256Token())
+		assert.Error(t, err)
+	})
+
+	t.Run("returns the plain-text secret for an allowed algorithm", func(t *testing.T) {
+		oidcClient := &hmacSecretClientFixture{secret: []byte("super-secret")}
+
+		secret, err := f.findClientHMACSecret(oidcClient, hs256Token())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("super-secret"), secret)
+	})
+}
+
+func TestHMACSecretClient(t *testing.T) {
+	client := &hmacSecretClientFixture{secret: []byte("super-secret")}
+	assert.Equal(t, []byte("super-secret"), client.GetClientSecretPlain())
+
+	var _ HMACClientSecretClient = client
+}
+
+// hmacSecretClientFixture implements OpenIDConnectClient (via
+// oidcClientFixture) plus HMACClientSecretClient, to exercise
+// findClientHMACSecret's success and empty-secret branches.
+type hmacSecretClientFixture struct {
+	oidcClientFixture
+
+	secret []byte
+}
+
+func (c *hmacSecretClientFixture) GetClientSecretPlain() []byte {
+	return c.secret
+}
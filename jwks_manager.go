@@ -0,0 +1,319 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	// DefaultJWKSMinSyncInterval is the lower bound applied to a JWKS
+	// endpoint's advertised cache lifetime, so that a misconfigured
+	// `max-age: 0` can't turn background sync into a request storm.
+	DefaultJWKSMinSyncInterval = time.Minute
+
+	// DefaultJWKSMaxSyncInterval is the upper bound applied to a JWKS
+	// endpoint's advertised cache lifetime, so that keys are still
+	// periodically refreshed even if the endpoint asks us to cache forever.
+	DefaultJWKSMaxSyncInterval = 24 * time.Hour
+
+	// jwksForceRefreshDebounce is the minimum time between two force
+	// refreshes of the same jwks_uri triggered by unknown-kid misses, to
+	// avoid a thundering herd of requests while a key rollover propagates.
+	jwksForceRefreshDebounce = 5 * time.Second
+
+	// defaultJWKSFetchTimeout bounds a single JWKS fetch, background or
+	// forced. Without it, an unreachable or slow-to-respond jwks_uri can
+	// block Close (which waits for the sync loop to leave the request) and,
+	// since ForceRefresh is called synchronously from findClientPublicJWK on
+	// an unknown-kid miss, a live token request too.
+	defaultJWKSFetchTimeout = 10 * time.Second
+)
+
+// jwksManagerEntry holds the last known-good keys for a single jwks_uri,
+// plus the bookkeeping needed to drive its background sync goroutine.
+type jwksManagerEntry struct {
+	mu               sync.RWMutex
+	keys             *jose.JSONWebKeySet
+	lastForceRefresh time.Time
+}
+
+// JWKSManager keeps a background-synced, per-jwks_uri cache of JSON Web Key
+// Sets, modeled after coreos/go-oidc's key sync loop. It exists so that
+// private_key_jwt / self_signed_tls_client_auth authentications against a
+// jwks_uri don't each pay the cost (and risk the failure mode) of a
+// synchronous refetch against the client's JWKS endpoint.
+//
+// Sync failures never evict the previously known keys: the manager keeps
+// serving the last successful result and retries with exponential backoff
+// and jitter until the endpoint recovers. An unknown `kid` is the only thing
+// that triggers an out-of-band refresh outside of the regular interval, and
+// that refresh is debounced per jwks_uri to avoid a thundering herd when a
+// key is rolled.
+type JWKSManager struct {
+	// client is a dedicated *http.Client rather than http.DefaultClient, so
+	// that mutating its Timeout can't affect unrelated code sharing the
+	// default client.
+	client *http.Client
+
+	minSyncInterval time.Duration
+	maxSyncInterval time.Duration
+
+	// OnSyncSuccess, if set, is called after every successful sync of a
+	// jwks_uri with the number of keys that were retrieved.
+	OnSyncSuccess func(uri string, keyCount int)
+
+	// OnSyncError, if set, is called after every failed sync attempt of a
+	// jwks_uri, before the manager falls back to the backoff/jitter retry.
+	OnSyncError func(uri string, err error)
+
+	mu      sync.Mutex
+	entries map[string]*jwksManagerEntry
+	wg      sync.WaitGroup
+	closed  chan struct{}
+}
+
+// NewJWKSManager creates a JWKSManager using DefaultJWKSMinSyncInterval and
+// DefaultJWKSMaxSyncInterval as the sync interval bounds.
+func NewJWKSManager() *JWKSManager {
+	return NewJWKSManagerWithIntervals(DefaultJWKSMinSyncInterval, DefaultJWKSMaxSyncInterval)
+}
+
+// NewJWKSManagerWithIntervals creates a JWKSManager whose per-uri sync
+// interval, derived from the `Cache-Control: max-age` / `Expires` response
+// headers, is clamped to [minSyncInterval, maxSyncInterval].
+func NewJWKSManagerWithIntervals(minSyncInterval, maxSyncInterval time.Duration) *JWKSManager {
+	return &JWKSManager{
+		client:          &http.Client{Timeout: defaultJWKSFetchTimeout},
+		minSyncInterval: minSyncInterval,
+		maxSyncInterval: maxSyncInterval,
+		entries:         make(map[string]*jwksManagerEntry),
+		closed:          make(chan struct{}),
+	}
+}
+
+// Keys returns the currently known keys for uri, and whether any sync of
+// uri has ever succeeded. It registers uri for background sync (starting
+// its goroutine) if this is the first time it is seen.
+func (m *JWKSManager) Keys(uri string) (*jose.JSONWebKeySet, bool) {
+	entry := m.register(uri)
+
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
+	return entry.keys, entry.keys != nil
+}
+
+// ForceRefresh triggers an out-of-band sync of uri, debounced so that
+// repeated unknown-kid misses within jwksForceRefreshDebounce only cause a
+// single extra request.
+func (m *JWKSManager) ForceRefresh(uri string) {
+	entry := m.register(uri)
+
+	entry.mu.Lock()
+	if time.Since(entry.lastForceRefresh) < jwksForceRefreshDebounce {
+		entry.mu.Unlock()
+		return
+	}
+	entry.lastForceRefresh = time.Now()
+	entry.mu.Unlock()
+
+	if keys, _, err := m.fetch(uri); err == nil {
+		entry.mu.Lock()
+		entry.keys = keys
+		entry.mu.Unlock()
+		if m.OnSyncSuccess != nil {
+			m.OnSyncSuccess(uri, len(keys.Keys))
+		}
+	} else if m.OnSyncError != nil {
+		m.OnSyncError(uri, err)
+	}
+}
+
+// Close stops every background sync goroutine. It is safe to call Close
+// more than once.
+func (m *JWKSManager) Close() error {
+	m.mu.Lock()
+	select {
+	case <-m.closed:
+	default:
+		close(m.closed)
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return nil
+}
+
+// register returns the entry for uri, creating it and starting its
+// background sync goroutine if this is the first time uri is seen.
+func (m *JWKSManager) register(uri string) *jwksManagerEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.entries[uri]; ok {
+		return entry
+	}
+
+	entry := &jwksManagerEntry{}
+	m.entries[uri] = entry
+
+	m.wg.Add(1)
+	go m.syncLoop(uri, entry)
+
+	return entry
+}
+
+// syncLoop periodically refetches uri until the manager is closed, backing
+// off with jitter on failure and re-arming from the response's advertised
+// cache lifetime on success.
+func (m *JWKSManager) syncLoop(uri string, entry *jwksManagerEntry) {
+	defer m.wg.Done()
+
+	backoff := m.minSyncInterval
+	for {
+		keys, maxAge, err := m.fetch(uri)
+
+		var wait time.Duration
+		if err != nil {
+			if m.OnSyncError != nil {
+				m.OnSyncError(uri, err)
+			}
+
+			wait = withJitter(backoff)
+			backoff *= 2
+			if backoff > m.maxSyncInterval {
+				backoff = m.maxSyncInterval
+			}
+		} else {
+			entry.mu.Lock()
+			entry.keys = keys
+			entry.mu.Unlock()
+
+			if m.OnSyncSuccess != nil {
+				m.OnSyncSuccess(uri, len(keys.Keys))
+			}
+
+			backoff = m.minSyncInterval
+			wait = clampDuration(maxAge, m.minSyncInterval, m.maxSyncInterval)
+		}
+
+		select {
+		case <-m.closed:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// fetch retrieves and parses the JWKS at uri, returning the keys and the
+// cache lifetime advertised via the `Cache-Control: max-age` or `Expires`
+// response headers (zero if neither is present).
+func (m *JWKSManager) fetch(uri string) (*jose.JSONWebKeySet, time.Duration, error) {
+	resp, err := m.client.Get(uri)
+	if err != nil {
+		return nil, 0, errorsx.WithStack(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, 0, errorsx.WithStack(ErrInvalidRequest.WithHintf("Expected successful status code when retrieving JSON Web Key Set from '%s' but got code '%s'.", uri, resp.Status))
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, 0, errorsx.WithStack(err)
+	}
+
+	return &keys, cacheLifetime(resp), nil
+}
+
+// cacheLifetime derives how long a JWKS response may be cached from its
+// `Cache-Control: max-age` directive, falling back to the `Expires` header.
+func cacheLifetime(resp *http.Response) time.Duration {
+	if cc := resp.Header.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			directive = strings.TrimSpace(directive)
+			if !strings.HasPrefix(directive, "max-age") {
+				continue
+			}
+
+			parts := strings.SplitN(directive, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			if seconds, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+
+	if expires := resp.Header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			if d := time.Until(t); d > 0 {
+				return d
+			}
+		}
+	}
+
+	return 0
+}
+
+// clampDuration clamps d to [min, max], substituting max when d is zero
+// (i.e. no cache lifetime was advertised by the server).
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d <= 0 {
+		return max
+	}
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// minBackoffFloor is the smallest backoff withJitter will ever compute,
+// applied even if the manager was configured with a zero minSyncInterval -
+// without it, a zero interval feeds rand.Int63n(0), which panics.
+const minBackoffFloor = 100 * time.Millisecond
+
+// withJitter adds up to +/-20% of jitter to d, to avoid every registered
+// jwks_uri's backoff retries lining up in lockstep.
+func withJitter(d time.Duration) time.Duration {
+	if d < minBackoffFloor {
+		d = minBackoffFloor
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(d)*2/5)) - d/5
+	return d + jitter
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func TestWithJitter(t *testing.T) {
+	t.Run("never panics on a zero duration", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			withJitter(0)
+		})
+	})
+
+	t.Run("stays within +/-20%% of the input once above the floor", func(t *testing.T) {
+		d := time.Minute
+		for i := 0; i < 50; i++ {
+			got := withJitter(d)
+			assert.GreaterOrEqual(t, int64(got), int64(d*4/5))
+			assert.LessOrEqual(t, int64(got), int64(d*6/5))
+		}
+	})
+}
+
+func TestClampDuration(t *testing.T) {
+	min, max := time.Minute, time.Hour
+
+	assert.Equal(t, max, clampDuration(0, min, max))
+	assert.Equal(t, min, clampDuration(time.Second, min, max))
+	assert.Equal(t, max, clampDuration(2*time.Hour, min, max))
+	assert.Equal(t, 30*time.Minute, clampDuration(30*time.Minute, min, max))
+}
+
+func TestCacheLifetime(t *testing.T) {
+	t.Run("reads max-age from Cache-Control", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{"Cache-Control": []string{"public, max-age=120"}}}
+		assert.Equal(t, 120*time.Second, cacheLifetime(resp))
+	})
+
+	t.Run("falls back to Expires", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{"Expires": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}}
+		lifetime := cacheLifetime(resp)
+		assert.Greater(t, int64(lifetime), int64(55*time.Minute))
+	})
+
+	t.Run("defaults to zero with neither header", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), cacheLifetime(&http.Response{Header: http.Header{}}))
+	})
+}
+
+func TestJWKSManagerSyncsAndCaches(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keySet := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "kid-1", Use: "sig", Key: &key.PublicKey}}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=3600")
+		require.NoError(t, json.NewEncoder(w).Encode(keySet))
+	}))
+	defer server.Close()
+
+	manager := NewJWKSManagerWithIntervals(time.Millisecond, time.Hour)
+	defer manager.Close()
+
+	require.Eventually(t, func() bool {
+		_, ok := manager.Keys(server.URL)
+		return ok
+	}, time.Second, time.Millisecond, "expected the background sync goroutine to populate keys")
+
+	keys, ok := manager.Keys(server.URL)
+	require.True(t, ok)
+	assert.Len(t, keys.Keys, 1)
+	assert.Equal(t, "kid-1", keys.Keys[0].KeyID)
+}
+
+func TestJWKSManagerForceRefreshIsDebounced(t *testing.T) {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		require.NoError(t, json.NewEncoder(w).Encode(&jose.JSONWebKeySet{}))
+	}))
+	defer server.Close()
+
+	manager := NewJWKSManagerWithIntervals(time.Hour, time.Hour)
+	defer manager.Close()
+
+	_, _ = manager.Keys(server.URL)
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&requests) >= 1 }, time.Second, time.Millisecond)
+
+	before := atomic.LoadInt64(&requests)
+	manager.ForceRefresh(server.URL)
+	manager.ForceRefresh(server.URL)
+	manager.ForceRefresh(server.URL)
+
+	assert.Equal(t, before+1, atomic.LoadInt64(&requests), "repeated force refreshes within the debounce window should only cause one extra request")
+}
+
+// hangingListener accepts connections but never writes a response, modeling
+// an unreachable or wedged jwks_uri.
+func hangingListener(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn // read nothing, write nothing, just hold the connection open
+		}
+	}()
+
+	return listener
+}
+
+func TestJWKSManagerFetchIsBoundedByATimeout(t *testing.T) {
+	listener := hangingListener(t)
+	defer listener.Close()
+
+	manager := NewJWKSManagerWithIntervals(time.Hour, time.Hour)
+	manager.client.Timeout = 200 * time.Millisecond
+
+	uri := fmt.Sprintf("http://%s/jwks.json", listener.Addr().String())
+
+	done := make(chan struct{})
+	go func() {
+		manager.ForceRefresh(uri)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ForceRefresh against an unresponsive jwks_uri did not return within the fetch timeout")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		_ = manager.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return within the fetch timeout while a sync goroutine was mid-fetch")
+	}
+}
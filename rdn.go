@@ -0,0 +1,308 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"strconv"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// rdnAttributeTypeAliases maps the short and long attribute type names
+// defined by RFC 4514 section 3 to their numeric OID string form, so that
+// "CN", "commonName" and "2.5.4.3" are all treated as equivalent.
+var rdnAttributeTypeAliases = map[string]string{
+	"cn":                      "2.5.4.3",
+	"commonname":              "2.5.4.3",
+	"l":                       "2.5.4.7",
+	"localityname":            "2.5.4.7",
+	"st":                      "2.5.4.8",
+	"stateorprovincename":     "2.5.4.8",
+	"o":                       "2.5.4.10",
+	"organizationname":        "2.5.4.10",
+	"ou":                      "2.5.4.11",
+	"organizationalunitname":  "2.5.4.11",
+	"c":                       "2.5.4.6",
+	"countryname":             "2.5.4.6",
+	"street":                  "2.5.4.9",
+	"streetaddress":           "2.5.4.9",
+	"dc":                      "0.9.2342.19200300.100.1.25",
+	"domaincomponent":         "0.9.2342.19200300.100.1.25",
+	"uid":                     "0.9.2342.19200300.100.1.1",
+	"userid":                  "0.9.2342.19200300.100.1.1",
+}
+
+// rdnAttributeTypeAndValue is a single AttributeTypeAndValue of an RDN,
+// normalized for comparison: the type is resolved to its numeric OID and
+// the value has been unescaped and string-prepped.
+type rdnAttributeTypeAndValue struct {
+	Type  string
+	Value string
+}
+
+// MatchRDNSequence reports whether the RFC 4514 distinguished name `raw`
+// structurally matches the RDNSequence of a parsed certificate Subject
+// (cert.Subject.ToRDNSequence()).
+//
+// Two DNs match iff they contain the same RDNs in the same order, each RDN
+// has the same set of AttributeTypeAndValue pairs (order-independent within
+// the set), and values are compared after string-prep: case-insensitive for
+// DirectoryString-typed values, with leading/trailing/duplicate whitespace
+// collapsed. Numeric OIDs and short/long attribute type aliases (CN,
+// commonName, 2.5.4.3, ...) are treated as equivalent.
+//
+// This replaces the historical `strings.Contains(cert.Subject.String(), ...)`
+// comparison, which could false-positive on DN substrings that happen to
+// overlap without actually matching the same RDN structure.
+func MatchRDNSequence(raw string, sequence pkix.RDNSequence) (bool, error) {
+	want, err := parseRDNSequence(raw)
+	if err != nil {
+		return false, err
+	}
+
+	// pkix.RDNSequence follows the wire (ASN.1) order of an X.509 Name,
+	// which lists RDNs least-specific-first (e.g. C, O, OU, ..., CN). A
+	// RFC 4514 DN string - and therefore the `want` sequence we just
+	// parsed - lists them most-specific-first (e.g. CN, OU, O, C), which
+	// is also what pkix.Name.String() prints. Reverse one side so that
+	// index i in both slices refers to the same RDN.
+	got := reverseRDNs(normalizeRDNSequence(sequence))
+	if len(want) != len(got) {
+		return false, nil
+	}
+
+	for i := range want {
+		if !rdnSetEquals(want[i], got[i]) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// reverseRDNs returns a copy of rdns in reverse order.
+func reverseRDNs(rdns [][]rdnAttributeTypeAndValue) [][]rdnAttributeTypeAndValue {
+	reversed := make([][]rdnAttributeTypeAndValue, len(rdns))
+	for i, rdn := range rdns {
+		reversed[len(rdns)-1-i] = rdn
+	}
+	return reversed
+}
+
+// normalizeRDNSequence converts a pkix.RDNSequence, as produced by the
+// standard library's x509 parser, into our normalized representation.
+func normalizeRDNSequence(sequence pkix.RDNSequence) [][]rdnAttributeTypeAndValue {
+	rdns := make([][]rdnAttributeTypeAndValue, 0, len(sequence))
+	for _, rdn := range sequence {
+		atvs := make([]rdnAttributeTypeAndValue, 0, len(rdn))
+		for _, atv := range rdn {
+			value, ok := atv.Value.(string)
+			if !ok {
+				continue
+			}
+			atvs = append(atvs, rdnAttributeTypeAndValue{
+				Type:  atv.Type.String(),
+				Value: stringPrep(value),
+			})
+		}
+		rdns = append(rdns, atvs)
+	}
+
+	return rdns
+}
+
+// rdnSetEquals compares two RDNs (sets of AttributeTypeAndValue) for
+// equality, independent of attribute order within the set.
+func rdnSetEquals(a, b []rdnAttributeTypeAndValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	used := make([]bool, len(b))
+	for _, atv := range a {
+		found := false
+		for i, other := range b {
+			if used[i] {
+				continue
+			}
+			if atv.Type == other.Type && atv.Value == other.Value {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stringPrep normalizes a DirectoryString value for comparison: folds case
+// and collapses runs of whitespace to a single space, trimming the ends.
+func stringPrep(s string) string {
+	fields := strings.Fields(s)
+	return strings.ToLower(strings.Join(fields, " "))
+}
+
+// parseRDNSequence parses a RFC 4514 distinguished name string, e.g.
+// `CN=John Doe,O=Example\, Inc.,C=US`, into a sequence of RDNs ordered as
+// they appear in the string (most-specific first, matching Go's
+// pkix.Name.String() ordering; cert.Subject.ToRDNSequence() lists them in
+// the reverse order).
+func parseRDNSequence(raw string) ([][]rdnAttributeTypeAndValue, error) {
+	var rdns [][]rdnAttributeTypeAndValue
+	var rdn []rdnAttributeTypeAndValue
+
+	for len(raw) > 0 {
+		atv, rest, err := parseAttributeTypeAndValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		rdn = append(rdn, atv)
+
+		rest = strings.TrimLeft(rest, " ")
+		switch {
+		case len(rest) == 0:
+			rdns = append(rdns, rdn)
+			rdn = nil
+			raw = rest
+		case rest[0] == '+':
+			raw = strings.TrimLeft(rest[1:], " ")
+		case rest[0] == ',' || rest[0] == ';':
+			rdns = append(rdns, rdn)
+			rdn = nil
+			raw = strings.TrimLeft(rest[1:], " ")
+		default:
+			return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("Unexpected character '%c' while parsing distinguished name '%s'.", rest[0], raw))
+		}
+	}
+
+	if rdn != nil {
+		rdns = append(rdns, rdn)
+	}
+
+	return rdns, nil
+}
+
+// parseAttributeTypeAndValue parses a single `type=value` pair from the
+// front of raw, returning the parsed, normalized pair and the unconsumed
+// remainder (which starts with the next '+', ',' or ';' separator, if any).
+func parseAttributeTypeAndValue(raw string) (rdnAttributeTypeAndValue, string, error) {
+	eq := strings.IndexByte(raw, '=')
+	if eq < 0 {
+		return rdnAttributeTypeAndValue{}, "", errorsx.WithStack(ErrInvalidRequest.WithHintf("Expected '=' while parsing distinguished name attribute in '%s'.", raw))
+	}
+
+	typ := resolveAttributeType(strings.TrimSpace(raw[:eq]))
+	value, rest, err := parseAttributeValue(raw[eq+1:])
+	if err != nil {
+		return rdnAttributeTypeAndValue{}, "", err
+	}
+
+	return rdnAttributeTypeAndValue{Type: typ, Value: stringPrep(value)}, rest, nil
+}
+
+// resolveAttributeType resolves a short or long RFC 4514 attribute type
+// name, or a dotted-decimal OID (optionally prefixed with "OID." or
+// "oid."), to its canonical numeric OID string.
+func resolveAttributeType(typ string) string {
+	lower := strings.ToLower(typ)
+	lower = strings.TrimPrefix(lower, "oid.")
+
+	if alias, ok := rdnAttributeTypeAliases[lower]; ok {
+		return alias
+	}
+
+	if oid, err := parseNumericOID(lower); err == nil {
+		return oid
+	}
+
+	return lower
+}
+
+// parseNumericOID validates that s is a dotted-decimal OID (e.g.
+// "2.5.4.3") and returns it unchanged as its canonical string form.
+func parseNumericOID(s string) (string, error) {
+	var oid asn1.ObjectIdentifier
+	parts := strings.Split(s, ".")
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return "", err
+		}
+		oid = append(oid, n)
+	}
+
+	return oid.String(), nil
+}
+
+// parseAttributeValue parses a single RFC 4514 attribute value, handling
+// the `\,`, `\+`, `\"`, `\\`, `\<`, `\>`, `\;`, leading `#`/space, trailing
+// space and `\xx` hex escapes, and returns the unescaped value together
+// with the unconsumed remainder of the input.
+func parseAttributeValue(raw string) (string, string, error) {
+	var value strings.Builder
+	i := 0
+
+	// A leading '#' or space is itself escaped by a leading backslash in
+	// RFC 4514; without that escape it is just part of the plain value.
+	for i < len(raw) {
+		c := raw[i]
+
+		switch {
+		case c == '\\':
+			if i+1 >= len(raw) {
+				return "", "", errorsx.WithStack(ErrInvalidRequest.WithHint("Distinguished name ends with a trailing unescaped '\\'."))
+			}
+
+			if isHex(raw[i+1]) && i+2 < len(raw) && isHex(raw[i+2]) {
+				b, err := strconv.ParseUint(raw[i+1:i+3], 16, 8)
+				if err != nil {
+					return "", "", errorsx.WithStack(ErrInvalidRequest.WithHint("Invalid hex escape in distinguished name."))
+				}
+				value.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+
+			value.WriteByte(raw[i+1])
+			i += 2
+			continue
+		case c == ',' || c == '+' || c == ';':
+			return value.String(), raw[i:], nil
+		default:
+			value.WriteByte(c)
+			i++
+		}
+	}
+
+	return value.String(), "", nil
+}
+
+func isHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustSelfSignedCert(t *testing.T, subject pkix.Name) *x509.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      subject,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestMatchRDNSequence(t *testing.T) {
+	cert := mustSelfSignedCert(t, pkix.Name{
+		CommonName:         "client.example.com",
+		OrganizationalUnit: []string{"Engineering"},
+		Organization:       []string{"Example Inc"},
+		Country:            []string{"US"},
+	})
+
+	t.Run("a certificate's own Subject.String() matches its own RDNSequence", func(t *testing.T) {
+		matched, err := MatchRDNSequence(cert.Subject.String(), cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.True(t, matched, "expected %q to match its own certificate Subject", cert.Subject.String())
+	})
+
+	t.Run("matches using long attribute names and the 'OID.' prefix", func(t *testing.T) {
+		matched, err := MatchRDNSequence("commonName=client.example.com,OU=Engineering,O=Example Inc,OID.2.5.4.6=US", cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("is case-insensitive and collapses whitespace", func(t *testing.T) {
+		matched, err := MatchRDNSequence("CN=  Client.Example.COM ,OU=engineering,O=example   inc,C=us", cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("does not match when a RDN value differs", func(t *testing.T) {
+		matched, err := MatchRDNSequence("CN=other.example.com,OU=Engineering,O=Example Inc,C=US", cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("does not match a DN substring, unlike the historical strings.Contains check", func(t *testing.T) {
+		matched, err := MatchRDNSequence("OU=Engineering,O=Example Inc,C=US", cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.False(t, matched, "a partial DN must not match")
+	})
+
+	t.Run("does not match when RDN order differs", func(t *testing.T) {
+		matched, err := MatchRDNSequence("C=US,O=Example Inc,OU=Engineering,CN=client.example.com", cert.Subject.ToRDNSequence())
+		require.NoError(t, err)
+		assert.False(t, matched)
+	})
+
+	t.Run("matches a multi-valued RDN independent of attribute order", func(t *testing.T) {
+		sequence := pkix.RDNSequence{
+			pkix.RelativeDistinguishedNameSET{
+				pkix.AttributeTypeAndValue{Type: asn1.ObjectIdentifier{2, 5, 4, 3}, Value: "client.example.com"},
+				pkix.AttributeTypeAndValue{Type: asn1.ObjectIdentifier{2, 5, 4, 10}, Value: "Example Inc"},
+			},
+		}
+
+		matched, err := MatchRDNSequence("O=Example Inc+CN=client.example.com", sequence)
+		require.NoError(t, err)
+		assert.True(t, matched)
+	})
+
+	t.Run("rejects a malformed DN", func(t *testing.T) {
+		_, err := MatchRDNSequence("CN client.example.com", cert.Subject.ToRDNSequence())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"github.com/narg95/fosite"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// DefaultClient is a minimal, storage-agnostic example implementation of
+// fosite.Client / fosite.OpenIDConnectClient. Method-specific example types
+// in this package (see HMACSecretClient) embed it so that they are usable
+// on their own as a drop-in store entry, rather than only satisfying the
+// one optional interface they were written to demonstrate. A real store's
+// client type will usually carry more fields than this (redirect URIs,
+// grant types, scopes, ...); this only covers what fosite's client
+// authentication code reads.
+type DefaultClient struct {
+	ID                                string
+	HashedSecret                      []byte
+	Public                            bool
+	CertificateSubjectFieldName       string
+	CertificateSubjectValue           string
+	JSONWebKeysURI                    string
+	TokenEndpointAuthMethod           string
+	TokenEndpointAuthSigningAlgorithm string
+}
+
+// GetID implements fosite.Client.
+func (c *DefaultClient) GetID() string { return c.ID }
+
+// GetHashedSecret implements fosite.Client.
+func (c *DefaultClient) GetHashedSecret() []byte { return c.HashedSecret }
+
+// IsPublic implements fosite.Client.
+func (c *DefaultClient) IsPublic() bool { return c.Public }
+
+// GetCertificateSubjectFieldName implements fosite.Client.
+func (c *DefaultClient) GetCertificateSubjectFieldName() string { return c.CertificateSubjectFieldName }
+
+// GetCertificateSubjectValue implements fosite.Client.
+func (c *DefaultClient) GetCertificateSubjectValue() string { return c.CertificateSubjectValue }
+
+// GetJSONWebKeys implements fosite.OpenIDConnectClient. This example always
+// registers its keys via a URI rather than inline, so it always returns nil;
+// a store that wants inline keys should override this method.
+func (c *DefaultClient) GetJSONWebKeys() *jose.JSONWebKeySet { return nil }
+
+// GetJSONWebKeysURI implements fosite.OpenIDConnectClient.
+func (c *DefaultClient) GetJSONWebKeysURI() string { return c.JSONWebKeysURI }
+
+// GetTokenEndpointAuthMethod implements fosite.OpenIDConnectClient.
+func (c *DefaultClient) GetTokenEndpointAuthMethod() string { return c.TokenEndpointAuthMethod }
+
+// GetTokenEndpointAuthSigningAlgorithm implements fosite.OpenIDConnectClient.
+func (c *DefaultClient) GetTokenEndpointAuthSigningAlgorithm() string {
+	return c.TokenEndpointAuthSigningAlgorithm
+}
+
+var _ fosite.OpenIDConnectClient = (*DefaultClient)(nil)
@@ -0,0 +1,51 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import "github.com/narg95/fosite"
+
+// HMACSecretClient is an example Client implementation for stores that want
+// to support the 'client_secret_jwt' authentication method (see
+// fosite.HMACClientSecretClient). It embeds DefaultClient so that it is
+// usable as a drop-in store entry on its own, not just something that
+// happens to satisfy fosite.HMACClientSecretClient in isolation. Because
+// verifying a client_secret_jwt assertion requires the plain-text secret,
+// this example keeps it alongside the hashed secret rather than deriving one
+// from the other - which is the approach any real store wishing to support
+// this method must take, since fosite.Client.GetHashedSecret is one-way and
+// cannot be recovered.
+type HMACSecretClient struct {
+	*DefaultClient
+
+	// ClientSecretPlain is the client's plain-text secret (or a dedicated
+	// HMAC key, if the store prefers to keep it distinct from the hashed
+	// secret used for 'client_secret_basic' / 'client_secret_post').
+	ClientSecretPlain []byte
+}
+
+// GetClientSecretPlain implements fosite.HMACClientSecretClient.
+func (c *HMACSecretClient) GetClientSecretPlain() []byte {
+	return c.ClientSecretPlain
+}
+
+var _ fosite.HMACClientSecretClient = (*HMACSecretClient)(nil)
+var _ fosite.OpenIDConnectClient = (*HMACSecretClient)(nil)
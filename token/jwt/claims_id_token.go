@@ -42,7 +42,17 @@ type IDTokenClaims struct {
 	AuthenticationContextClassReference string
 	AuthenticationMethodsReference      string
 	CodeHash                            string
-	Extra                               map[string]interface{}
+	// ClientCertificateThumbprint, if set, is emitted as the RFC 8705
+	// 'cnf: {"x5t#S256": "..."}' confirmation claim, binding the issued
+	// token to the TLS client certificate the client authenticated with.
+	// Nothing in this package sets it automatically - whatever builds an
+	// IDTokenClaims for a token response must call
+	// fosite.ApplyClientCertificateThumbprint to copy it in from the
+	// request's Session first. This package doesn't contain that
+	// token-issuance call site, so until one calls it, this field is
+	// always empty and no 'cnf' claim is ever emitted.
+	ClientCertificateThumbprint string
+	Extra                       map[string]interface{}
 }
 
 // ToMap will transform the headers to a map structure
@@ -127,6 +137,12 @@ func (c *IDTokenClaims) ToMap() map[string]interface{} {
 		delete(ret, "amr")
 	}
 
+	if len(c.ClientCertificateThumbprint) > 0 {
+		ret["cnf"] = map[string]string{"x5t#S256": c.ClientCertificateThumbprint}
+	} else {
+		delete(ret, "cnf")
+	}
+
 	return ret
 
 }
@@ -0,0 +1,184 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+
+	"github.com/narg95/fosite/token/jwt"
+)
+
+// This file implements RFC 8705 section 3 (certificate-bound access
+// tokens) up to the point where it meets code this package doesn't
+// contain. What's real end to end: a successful mTLS authentication
+// stores the certificate's thumbprint on the request's Session
+// (BindClientCertificate), and a later resource request's certificate can
+// be checked against it (TokenBoundToClientCertificate). What's only a
+// building block, because this package has no JWT access-token issuance
+// path and no introspection endpoint to call it from: ApplyClientCertificateThumbprint
+// (copies the bound thumbprint onto an IDTokenClaims before signing) and
+// IntrospectionConfirmation (builds the 'cnf' map an introspection
+// response would merge in). Whatever owns those call sites still has to
+// invoke them; their mere existence here isn't evidence that a 'cnf' claim
+// or introspection response comes out the other end of this tree.
+
+// MetadataTLSClientCertificateBoundAccessTokens is the authorization server
+// metadata flag, per RFC 8705 section 3.3, that advertises support for
+// certificate-bound access tokens.
+const MetadataTLSClientCertificateBoundAccessTokens = "tls_client_certificate_bound_access_tokens"
+
+// ConfirmationClaimKey is the JWT claim (RFC 7800) under which a proof-of-
+// possession confirmation method is carried, e.g. cnf: {"x5t#S256": "..."}.
+const ConfirmationClaimKey = "cnf"
+
+// X5TS256ConfirmationKey is the RFC 8705 section 3.1 confirmation method
+// name for a certificate's base64url-encoded SHA-256 thumbprint.
+const X5TS256ConfirmationKey = "x5t#S256"
+
+// CertificateBoundAccessTokensClient is implemented by clients that have
+// opted into having their access and refresh tokens bound to the TLS
+// client certificate they authenticated with, by setting the
+// 'tls_client_certificate_bound_access_tokens' client metadata flag.
+type CertificateBoundAccessTokensClient interface {
+	GetTLSClientCertificateBoundAccessTokens() bool
+}
+
+// ClientCertificateThumbprintSession is implemented by Session types that
+// want to support RFC 8705 section 3 certificate-bound access/refresh
+// tokens. BindClientCertificate populates it once, right after a
+// successful mTLS client authentication; token issuance and introspection
+// code paths read it back to confirm or expose the binding.
+type ClientCertificateThumbprintSession interface {
+	SetClientCertificateThumbprint(thumbprint string)
+	GetClientCertificateThumbprint() string
+}
+
+// sessionContextKey is the context.Context key ContextWithSession stores a
+// request's Session under.
+type sessionContextKey struct{}
+
+// ContextWithSession returns a copy of ctx carrying session, so that
+// AuthenticateClient's MTLSClientAuthenticator tier can record a successful
+// mTLS authentication's certificate-bound-access-token binding via
+// BindClientCertificate. Callers that don't support RFC 8705 certificate
+// binding can simply pass ctx through unchanged; there's nothing to opt out
+// of.
+func ContextWithSession(ctx context.Context, session interface{}) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// sessionFromContext returns the Session stored by ContextWithSession, or
+// nil if ctx doesn't carry one.
+func sessionFromContext(ctx context.Context) interface{} {
+	return ctx.Value(sessionContextKey{})
+}
+
+// BindClientCertificate records the SHA-256 thumbprint of the TLS client
+// certificate presented on r onto session, so that the access/refresh
+// token issued for this request can later be verified as certificate-bound
+// via TokenBoundToClientCertificate. It is a no-op unless the client has
+// opted in via CertificateBoundAccessTokensClient and the session supports
+// ClientCertificateThumbprintSession - callers don't need to guard those
+// cases themselves. client is typed as interface{}, like session, because
+// only that optional sub-interface is ever consulted.
+func BindClientCertificate(r *http.Request, client interface{}, session interface{}) {
+	boundClient, ok := client.(CertificateBoundAccessTokensClient)
+	if !ok || !boundClient.GetTLSClientCertificateBoundAccessTokens() {
+		return
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return
+	}
+
+	tbSession, ok := session.(ClientCertificateThumbprintSession)
+	if !ok {
+		return
+	}
+
+	tbSession.SetClientCertificateThumbprint(certificateThumbprintSHA256(r.TLS.PeerCertificates[0]))
+}
+
+// TokenBoundToClientCertificate reports whether cert is the TLS client
+// certificate the access/refresh token held by session was bound to, i.e.
+// whether a resource request presenting cert is allowed to use that token.
+func TokenBoundToClientCertificate(session interface{}, cert *x509.Certificate) bool {
+	if cert == nil {
+		return false
+	}
+
+	tbSession, ok := session.(ClientCertificateThumbprintSession)
+	if !ok {
+		return false
+	}
+
+	thumbprint := tbSession.GetClientCertificateThumbprint()
+	return thumbprint != "" && thumbprint == certificateThumbprintSHA256(cert)
+}
+
+// ApplyClientCertificateThumbprint copies session's RFC 8705 certificate
+// thumbprint, if any, onto claims.ClientCertificateThumbprint, so that the
+// JWT built from claims carries the 'cnf: {"x5t#S256": "..."}' confirmation
+// claim (see IDTokenClaims.ClientCertificateThumbprint). It is a no-op if
+// session was never bound to a client certificate.
+//
+// This package doesn't contain the JWT access-token issuance path that
+// builds an IDTokenClaims from a request's Session, so nothing here calls
+// this automatically; it's the building block that path needs to call,
+// mirroring how BindClientCertificate is the building block
+// authenticateClientWithTLS already calls on successful authentication.
+func ApplyClientCertificateThumbprint(claims *jwt.IDTokenClaims, session interface{}) {
+	tbSession, ok := session.(ClientCertificateThumbprintSession)
+	if !ok {
+		return
+	}
+
+	claims.ClientCertificateThumbprint = tbSession.GetClientCertificateThumbprint()
+}
+
+// IntrospectionConfirmation returns the RFC 7800 'cnf' claim to include in
+// a token introspection response, so that a resource server can validate
+// that the TLS client certificate on the resource request matches the one
+// the token was bound to. It returns nil if session was never bound to a
+// client certificate.
+//
+// The introspection endpoint itself isn't part of this change series - this
+// package doesn't contain an introspection response path to call it from -
+// so for now this is the building block a future introspection handler
+// needs to merge into its response, mirroring how BindClientCertificate is
+// the building block authenticateClientWithTLS merges into a successful
+// authentication.
+func IntrospectionConfirmation(session interface{}) map[string]string {
+	tbSession, ok := session.(ClientCertificateThumbprintSession)
+	if !ok {
+		return nil
+	}
+
+	thumbprint := tbSession.GetClientCertificateThumbprint()
+	if thumbprint == "" {
+		return nil
+	}
+
+	return map[string]string{X5TS256ConfirmationKey: thumbprint}
+}
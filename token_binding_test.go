@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @Copyright 	2017-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/narg95/fosite/token/jwt"
+)
+
+type boundAccessTokensClient struct {
+	bound bool
+}
+
+func (c boundAccessTokensClient) GetTLSClientCertificateBoundAccessTokens() bool { return c.bound }
+
+type thumbprintSession struct {
+	thumbprint string
+}
+
+func (s *thumbprintSession) SetClientCertificateThumbprint(thumbprint string) {
+	s.thumbprint = thumbprint
+}
+func (s *thumbprintSession) GetClientCertificateThumbprint() string { return s.thumbprint }
+
+func requestWithPeerCert(cert *x509.Certificate) *http.Request {
+	r, _ := http.NewRequest("POST", "https://example.com/token", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	return r
+}
+
+func TestBindClientCertificate(t *testing.T) {
+	cert := mustCertWithSANs(t)
+
+	t.Run("binds the certificate thumbprint when the client opted in", func(t *testing.T) {
+		session := &thumbprintSession{}
+		BindClientCertificate(requestWithPeerCert(cert), boundAccessTokensClient{bound: true}, session)
+		assert.Equal(t, certificateThumbprintSHA256(cert), session.thumbprint)
+	})
+
+	t.Run("is a no-op when the client did not opt in", func(t *testing.T) {
+		session := &thumbprintSession{}
+		BindClientCertificate(requestWithPeerCert(cert), boundAccessTokensClient{bound: false}, session)
+		assert.Empty(t, session.thumbprint)
+	})
+
+	t.Run("is a no-op when the client doesn't implement CertificateBoundAccessTokensClient", func(t *testing.T) {
+		session := &thumbprintSession{}
+		BindClientCertificate(requestWithPeerCert(cert), struct{}{}, session)
+		assert.Empty(t, session.thumbprint)
+	})
+
+	t.Run("is a no-op when there is no peer certificate", func(t *testing.T) {
+		session := &thumbprintSession{}
+		r, _ := http.NewRequest("POST", "https://example.com/token", nil)
+		r.TLS = &tls.ConnectionState{}
+		BindClientCertificate(r, boundAccessTokensClient{bound: true}, session)
+		assert.Empty(t, session.thumbprint)
+	})
+
+	t.Run("is a no-op when the session doesn't support certificate binding", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			BindClientCertificate(requestWithPeerCert(cert), boundAccessTokensClient{bound: true}, struct{}{})
+		})
+	})
+}
+
+func TestTokenBoundToClientCertificate(t *testing.T) {
+	cert := mustCertWithSANs(t)
+	other := mustCertWithSANs(t)
+
+	session := &thumbprintSession{thumbprint: certificateThumbprintSHA256(cert)}
+
+	assert.True(t, TokenBoundToClientCertificate(session, cert))
+	assert.False(t, TokenBoundToClientCertificate(session, other))
+	assert.False(t, TokenBoundToClientCertificate(session, nil))
+	assert.False(t, TokenBoundToClientCertificate(struct{}{}, cert))
+	assert.False(t, TokenBoundToClientCertificate(&thumbprintSession{}, cert))
+}
+
+func TestApplyClientCertificateThumbprint(t *testing.T) {
+	cert := mustCertWithSANs(t)
+
+	t.Run("copies the bound thumbprint onto the claims", func(t *testing.T) {
+		session := &thumbprintSession{thumbprint: certificateThumbprintSHA256(cert)}
+		claims := &jwt.IDTokenClaims{}
+
+		ApplyClientCertificateThumbprint(claims, session)
+		assert.Equal(t, certificateThumbprintSHA256(cert), claims.ClientCertificateThumbprint)
+	})
+
+	t.Run("is a no-op when the session was never bound", func(t *testing.T) {
+		claims := &jwt.IDTokenClaims{}
+
+		ApplyClientCertificateThumbprint(claims, &thumbprintSession{})
+		assert.Empty(t, claims.ClientCertificateThumbprint)
+	})
+
+	t.Run("is a no-op when the session doesn't support certificate binding", func(t *testing.T) {
+		claims := &jwt.IDTokenClaims{}
+
+		assert.NotPanics(t, func() {
+			ApplyClientCertificateThumbprint(claims, struct{}{})
+		})
+		assert.Empty(t, claims.ClientCertificateThumbprint)
+	})
+}
+
+func TestIntrospectionConfirmation(t *testing.T) {
+	cert := mustCertWithSANs(t)
+
+	t.Run("returns the cnf claim when the session is bound", func(t *testing.T) {
+		session := &thumbprintSession{thumbprint: certificateThumbprintSHA256(cert)}
+		assert.Equal(t, map[string]string{X5TS256ConfirmationKey: certificateThumbprintSHA256(cert)}, IntrospectionConfirmation(session))
+	})
+
+	t.Run("returns nil when the session was never bound", func(t *testing.T) {
+		assert.Nil(t, IntrospectionConfirmation(&thumbprintSession{}))
+	})
+
+	t.Run("returns nil for a session that doesn't support certificate binding", func(t *testing.T) {
+		assert.Nil(t, IntrospectionConfirmation(struct{}{}))
+	})
+}